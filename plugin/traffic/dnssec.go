@@ -0,0 +1,191 @@
+package traffic
+
+import (
+	"crypto"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/miekg/dns"
+)
+
+// resignMargin is how far ahead of a cached RRSIG's actual expiration
+// signRRset re-signs rather than reuses it, so a signature never goes out
+// the door closer to invalid than this.
+const resignMargin = 1 * time.Hour
+
+// signer online-signs the synthetic RRsets the traffic plugin emits, caching
+// RRSIGs so repeated queries for a cluster don't re-sign on every lookup.
+type signer struct {
+	key     *dns.DNSKEY
+	private crypto.Signer
+
+	sigs *cache.Cache
+}
+
+// newSigner loads a DNSKEY/private key pair named keyFile (keyFile.key and
+// keyFile.private, the dnssec-keygen naming convention) and returns a signer
+// that can online-sign RRsets with it.
+func newSigner(keyFile string) (*signer, error) {
+	kf, err := os.Open(keyFile + ".key")
+	if err != nil {
+		return nil, err
+	}
+	defer kf.Close()
+
+	rr, err := dns.ReadRR(kf, keyFile+".key")
+	if err != nil {
+		return nil, err
+	}
+	dnskey, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain a DNSKEY", keyFile+".key")
+	}
+
+	pf, err := os.Open(keyFile + ".private")
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+
+	priv, err := dnskey.ReadPrivateKey(pf, keyFile+".private")
+	if err != nil {
+		return nil, err
+	}
+	s, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key in %s does not support signing", keyFile+".private")
+	}
+
+	return &signer{key: dnskey, private: s, sigs: cache.New(256)}, nil
+}
+
+// sign online-signs the RRsets in m.Answer, m.Ns and m.Extra, appending an
+// RRSIG for each RRset it finds. When m carries no answer (the NODATA/NXDOMAIN
+// path, where m.Ns is just the synthetic SOA) it also synthesizes an NSEC so
+// the denial of existence is authenticated; present lists any other RRtypes
+// that actually exist at the denied owner name (e.g. an A when an AAAA query
+// hit a v4-only endpoint), so the NSEC's type bitmap doesn't lie about them.
+// OPT pseudo-records in m.Extra (e.g. an echoed ECS option) are never signed.
+func (s *signer) sign(m *dns.Msg, zone string, present ...uint16) {
+	if len(m.Answer) == 0 && len(m.Ns) > 0 && len(m.Question) > 0 {
+		m.Ns = append(m.Ns, nsec(m.Question[0].Name, zone, present))
+	}
+
+	m.Answer = append(m.Answer, s.signRRsets(m.Answer, zone)...)
+	m.Ns = append(m.Ns, s.signRRsets(m.Ns, zone)...)
+	m.Extra = append(m.Extra, s.signRRsets(signableRRs(m.Extra), zone)...)
+}
+
+// signableRRs returns rrs with any OPT pseudo-record filtered out: OPT
+// carries EDNS metadata, not zone data, and must never be fed into an RRSIG.
+func signableRRs(rrs []dns.RR) []dns.RR {
+	out := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
+// rrsetKey identifies an RRset by its owner, type and class.
+type rrsetKey struct {
+	name  string
+	rtype uint16
+	class uint16
+}
+
+// signRRsets groups rrs into (name,type,class) RRsets and returns one RRSIG
+// per RRset.
+func (s *signer) signRRsets(rrs []dns.RR, zone string) []dns.RR {
+	sets := map[rrsetKey][]dns.RR{}
+	order := make([]rrsetKey, 0, len(rrs))
+	for _, rr := range rrs {
+		h := rr.Header()
+		k := rrsetKey{h.Name, h.Rrtype, h.Class}
+		if _, ok := sets[k]; !ok {
+			order = append(order, k)
+		}
+		sets[k] = append(sets[k], rr)
+	}
+
+	sigs := make([]dns.RR, 0, len(order))
+	for _, k := range order {
+		if rrsig := s.signRRset(sets[k], zone); rrsig != nil {
+			sigs = append(sigs, rrsig)
+		}
+	}
+	return sigs
+}
+
+// signRRset signs a single RRset, consulting and populating the signature
+// cache keyed on a hash over the canonical RRset.
+func (s *signer) signRRset(rrset []dns.RR, zone string) *dns.RRSIG {
+	if len(rrset) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+
+	h := hashRRset(rrset)
+	if sig, ok := s.sigs.Get(h); ok {
+		rrsig := sig.(*dns.RRSIG)
+		if rrsig.ValidityPeriod(now.Add(resignMargin)) {
+			return rrsig
+		}
+		s.sigs.Remove(h)
+	}
+	rrsig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: rrset[0].Header().Ttl},
+		Algorithm:  s.key.Algorithm,
+		OrigTtl:    5,
+		Inception:  uint32(now.Add(-3 * time.Hour).Unix()),
+		Expiration: uint32(now.Add(7 * 24 * time.Hour).Unix()),
+		KeyTag:     s.key.KeyTag(),
+		SignerName: zone,
+	}
+	if err := rrsig.Sign(s.private, rrset); err != nil {
+		log.Warningf("Failed to sign RRset for %q: %s", rrset[0].Header().Name, err)
+		return nil
+	}
+
+	s.sigs.Add(h, rrsig)
+	return rrsig
+}
+
+// hashRRset returns a stable hash over the canonical (sorted) RRset text, so
+// identical RRsets across queries hit the signature cache.
+func hashRRset(rrset []dns.RR) uint64 {
+	sorted := make([]dns.RR, len(rrset))
+	copy(sorted, rrset)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+
+	h := fnv.New64a()
+	for _, rr := range sorted {
+		h.Write([]byte(rr.String()))
+	}
+	return h.Sum64()
+}
+
+// nsec synthesizes a minimal NSEC proving denial of existence for name. It
+// points back to itself, since the plugin only ever needs to deny a single
+// synthetic owner name rather than walk a real zone. present carries any
+// RRtypes that genuinely exist at name (e.g. an A record when the NODATA was
+// for an AAAA query against a v4-only endpoint); per RFC 4035 Section 3.1.3
+// the type bitmap must include those alongside RRSIG/NSEC themselves.
+func nsec(name, zone string, present []uint16) dns.RR {
+	types := append([]uint16{dns.TypeRRSIG, dns.TypeNSEC}, present...)
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	return &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 5},
+		NextDomain: name,
+		TypeBitMap: types,
+	}
+}
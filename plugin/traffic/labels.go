@@ -0,0 +1,59 @@
+package traffic
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/traffic/xds"
+	"github.com/coredns/coredns/request"
+)
+
+// queryParts is the decomposition of a cluster-relative qname (the labels
+// left over after stripping the configured origin) into the pieces the
+// traffic plugin understands.
+type queryParts struct {
+	endpoint string // "endpoint-N", selects a single numbered endpoint.
+	tag      string // the label at t.tagLabel, a catalog-style filter tag.
+	proto    string // "_tcp" or "_grpclb._tcp", an SRV/gRPC-LB lookup.
+	cluster  string // the rightmost label: the actual cluster name.
+	ok       bool   // false when a label didn't match any recognized shape above; the qname is garbage.
+}
+
+// parseLabels decomposes labels (as returned by dns.SplitDomainName, so
+// left-to-right/most-specific-first) into its {endpoint, tag, proto, cluster}
+// components. t.tagLabel is the 0-based label index (from the left) that
+// tag_label designated as the tag/filter position; -1 disables tag parsing.
+// ok is false as soon as any label doesn't fit one of those shapes, so callers
+// can NXDOMAIN instead of serving a partial match.
+func (t *Traffic) parseLabels(labels []string) queryParts {
+	q := queryParts{cluster: labels[len(labels)-1], ok: true}
+
+	var proto []string
+	for i, l := range labels[:len(labels)-1] {
+		lower := strings.ToLower(l)
+		switch {
+		case strings.HasPrefix(lower, "endpoint-"):
+			q.endpoint = l
+		case lower == "_tcp" || lower == "_grpclb":
+			proto = append(proto, lower)
+		case t.tagLabel >= 0 && i == t.tagLabel:
+			q.tag = l
+		default:
+			q.ok = false
+		}
+	}
+	q.proto = strings.Join(proto, ".")
+	return q
+}
+
+// selectTag returns a single endpoint for cluster carrying tag.
+func (t *Traffic) selectTag(state request.Request, cluster, tag string) (*xds.SocketAddress, bool) {
+	defer timeSelection(cluster)()
+	return t.c.SelectFiltered(cluster, tag, t.health)
+}
+
+// allTag returns every (healthy) endpoint for cluster carrying tag, used to
+// build tag-filtered SRV responses.
+func (t *Traffic) allTag(state request.Request, cluster, tag string) ([]*xds.SocketAddress, bool) {
+	defer timeSelection(cluster)()
+	return t.c.AllFiltered(cluster, tag, t.health)
+}
@@ -0,0 +1,31 @@
+package traffic
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		tagLabel int
+		want     queryParts
+	}{
+		{"endpoint-3.web", -1, queryParts{endpoint: "endpoint-3", cluster: "web", ok: true}},
+		{"_tcp.web", -1, queryParts{proto: "_tcp", cluster: "web", ok: true}},
+		{"_grpclb._tcp.web", -1, queryParts{proto: "_grpclb._tcp", cluster: "web", ok: true}},
+		{"canary.web", 0, queryParts{tag: "canary", cluster: "web", ok: true}},
+		{"endpoint-3.garbage.web", -1, queryParts{endpoint: "endpoint-3", cluster: "web", ok: false}},
+		{"junk._tcp.web", -1, queryParts{proto: "_tcp", cluster: "web", ok: false}},
+	}
+
+	for _, tc := range tests {
+		tr := &Traffic{tagLabel: tc.tagLabel}
+		got := tr.parseLabels(dns.SplitDomainName(tc.name))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseLabels(%q, tagLabel=%d) = %+v, want %+v", tc.name, tc.tagLabel, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package traffic
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/plugin/traffic/xds"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// localityMapping maps a CIDR to the xDS locality its clients should be
+// considered part of; populated from the `locality` Corefile block.
+type localityMapping struct {
+	subnet   *net.IPNet
+	Locality xds.Locality
+}
+
+// localityFor returns the locality configured for ip, using a longest-prefix
+// match over the configured subnets. ok is false when no subnet covers ip.
+func localityFor(mappings []localityMapping, ip net.IP) (loc xds.Locality, ok bool) {
+	bestOnes := -1
+	for _, m := range mappings {
+		if ip == nil || !m.subnet.Contains(ip) {
+			continue
+		}
+		ones, _ := m.subnet.Mask.Size()
+		if ones > bestOnes {
+			bestOnes, loc, ok = ones, m.Locality, true
+		}
+	}
+	return loc, ok
+}
+
+// localityScope returns the prefix length (in bits) of the most specific
+// configured subnet covering ip, or 0 when none matches. Used as the ECS
+// SourceScope echoed back to the client; callers must only use this when
+// prefer_locality is actually configured, since otherwise the selection
+// never applied a locality bias and a nonzero scope would mis-advertise
+// cacheability per RFC 7871.
+func localityScope(mappings []localityMapping, ip net.IP) uint8 {
+	bestOnes := -1
+	for _, m := range mappings {
+		if ip == nil || !m.subnet.Contains(ip) {
+			continue
+		}
+		if ones, _ := m.subnet.Mask.Size(); ones > bestOnes {
+			bestOnes = ones
+		}
+	}
+	if bestOnes < 0 {
+		return 0
+	}
+	return uint8(bestOnes)
+}
+
+// clientSubnet returns the address to use for locality lookups: the EDNS0
+// Client Subnet address when the query carries one (also returning the
+// option itself, so the response can echo it back), otherwise the DNS
+// client's own source IP.
+func clientSubnet(state request.Request) (net.IP, *dns.EDNS0_SUBNET) {
+	if o := state.Req.IsEdns0(); o != nil {
+		for _, s := range o.Option {
+			if e, ok := s.(*dns.EDNS0_SUBNET); ok {
+				return e.Address, e
+			}
+		}
+	}
+	return net.ParseIP(state.IP()), nil
+}
+
+// echoECS appends an OPT RR to m.Extra echoing back the client's ECS option
+// with the scope prefix length used to compute the answer, per RFC 7871.
+func echoECS(state request.Request, subnet *dns.EDNS0_SUBNET, scope uint8, m *dns.Msg) {
+	if subnet == nil {
+		return
+	}
+	o := state.Req.IsEdns0()
+
+	reply := new(dns.OPT)
+	reply.Hdr.Name = "."
+	reply.Hdr.Rrtype = dns.TypeOPT
+	reply.SetUDPSize(o.UDPSize())
+	reply.SetDo(o.Do())
+	reply.Option = append(reply.Option, &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        subnet.Family,
+		SourceNetmask: subnet.SourceNetmask,
+		SourceScope:   scope,
+		Address:       subnet.Address,
+	})
+	m.Extra = append(m.Extra, reply)
+}
+
+// selectEndpoint is xds.Client.Select, biased towards the locality of state's
+// client (from ECS or its source IP) when prefer_locality is configured.
+func (t *Traffic) selectEndpoint(state request.Request, cluster string) (*xds.SocketAddress, bool) {
+	defer timeSelection(cluster)()
+
+	if t.preferLocality == "" {
+		return t.c.Select(cluster, t.health)
+	}
+	ip, _ := clientSubnet(state)
+	loc, _ := localityFor(t.localities, ip)
+	return t.c.SelectNear(cluster, t.health, loc, t.preferLocality)
+}
+
+// allEndpoints is xds.Client.All, biased towards the locality of state's
+// client when prefer_locality is configured.
+func (t *Traffic) allEndpoints(state request.Request, cluster string) ([]*xds.SocketAddress, bool) {
+	defer timeSelection(cluster)()
+
+	if t.preferLocality == "" {
+		return t.c.All(cluster, t.health)
+	}
+	ip, _ := clientSubnet(state)
+	loc, _ := localityFor(t.localities, ip)
+	return t.c.AllNear(cluster, t.health, loc, t.preferLocality)
+}
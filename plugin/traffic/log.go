@@ -0,0 +1,5 @@
+package traffic
+
+import clog "github.com/coredns/coredns/plugin/pkg/log"
+
+var log = clog.NewWithPlugin("traffic")
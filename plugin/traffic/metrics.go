@@ -0,0 +1,34 @@
+package traffic
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// requestCount counts traffic-plugin decisions, by cluster, query type and
+	// response code, for every ServeDNS/serveEndpoint return path.
+	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "traffic",
+		Name:      "request_count_total",
+		Help:      "Counter of requests made, by cluster, query type and response code.",
+	}, []string{"cluster", "qtype", "rcode"})
+
+	// selectionDuration times how long it takes to pick an endpoint for a cluster.
+	selectionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "traffic",
+		Name:      "selection_duration_seconds",
+		Help:      "Histogram of the time it takes to select an endpoint for a cluster.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"cluster"})
+)
+
+// timeSelection starts a timer for an endpoint selection against cluster;
+// call the returned function when the selection is done.
+func timeSelection(cluster string) func() {
+	timer := prometheus.NewTimer(selectionDuration.WithLabelValues(cluster))
+	return timer.ObserveDuration
+}
@@ -0,0 +1,181 @@
+package traffic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
+	"github.com/coredns/coredns/plugin/traffic/xds"
+)
+
+func init() { plugin.Register("traffic", setup) }
+
+func setup(c *caddy.Controller) error {
+	t, err := parseTraffic(c)
+	if err != nil {
+		return plugin.Error("traffic", err)
+	}
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, requestCount, selectionDuration, xds.HealthyEndpoints, xds.UpdatesTotal)
+		return nil
+	})
+
+	if t.mgmt != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.OnStartup(func() error {
+			go t.runADS(ctx)
+			return nil
+		})
+		c.OnShutdown(func() error {
+			cancel()
+			return nil
+		})
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		t.Next = next
+		return t
+	})
+
+	return nil
+}
+
+// runADS keeps t.c's endpoints in sync with the xDS management server at
+// t.mgmt by running Client.Run in a loop, reconnecting with a fixed backoff
+// whenever the ADS stream drops, until ctx is canceled.
+func (t *Traffic) runADS(ctx context.Context) {
+	for {
+		if err := t.c.Run(ctx, t.mgmt, t.node, t.tlsConfig); err != nil && ctx.Err() == nil {
+			log.Warningf("xDS stream to %q failed, reconnecting: %s", t.mgmt, err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func parseTraffic(c *caddy.Controller) (*Traffic, error) {
+	t := &Traffic{c: xds.New(), tagLabel: -1}
+
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) > 0 {
+			t.id = args[0]
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "origins":
+				origins := c.RemainingArgs()
+				if len(origins) == 0 {
+					return nil, c.ArgErr()
+				}
+				t.origins = origins
+			case "node":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				t.node = c.Val()
+			case "endpoint":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				t.mgmt = args[0]
+				t.hosts = args
+			case "tls":
+				args := c.RemainingArgs()
+				if len(args) > 3 {
+					return nil, c.ArgErr()
+				}
+				tlsConfig, err := pkgtls.NewTLSConfigFromArgs(args...)
+				if err != nil {
+					return nil, err
+				}
+				t.tlsConfig = tlsConfig
+			case "lb_policy":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				policy, ok := xds.ParseLBPolicy(c.Val())
+				if !ok {
+					return nil, fmt.Errorf("unknown lb_policy %q", c.Val())
+				}
+				t.c.LBPolicy = policy
+			case "tag_label":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				idx, err := strconv.Atoi(c.Val())
+				if err != nil || idx < 0 {
+					return nil, fmt.Errorf("tag_label must be a non-negative label index: %q", c.Val())
+				}
+				t.tagLabel = idx
+			case "locality":
+				for c.NextBlock() {
+					subnet := c.Val()
+					args := c.RemainingArgs()
+					if len(args) < 1 || len(args) > 3 {
+						return nil, c.ArgErr()
+					}
+					_, ipnet, err := net.ParseCIDR(subnet)
+					if err != nil {
+						return nil, err
+					}
+					loc := xds.Locality{Region: args[0]}
+					if len(args) > 1 {
+						loc.Zone = args[1]
+					}
+					if len(args) > 2 {
+						loc.SubZone = args[2]
+					}
+					t.localities = append(t.localities, localityMapping{subnet: ipnet, Locality: loc})
+				}
+			case "prefer_locality":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				switch c.Val() {
+				case "region", "zone", "subzone":
+					t.preferLocality = c.Val()
+				default:
+					return nil, fmt.Errorf("unknown prefer_locality %q", c.Val())
+				}
+			case "dnssec":
+				for c.NextBlock() {
+					switch c.Val() {
+					case "key":
+						args := c.RemainingArgs()
+						if len(args) != 2 || args[0] != "file" {
+							return nil, c.ArgErr()
+						}
+						s, err := newSigner(args[1])
+						if err != nil {
+							return nil, err
+						}
+						t.signer = s
+					default:
+						return nil, c.ArgErr()
+					}
+				}
+			default:
+				return nil, c.ArgErr()
+			}
+		}
+	}
+
+	return t, nil
+}
@@ -28,6 +28,13 @@ type Traffic struct {
 	health  bool
 	origins []string
 
+	signer *signer
+
+	localities     []localityMapping
+	preferLocality string // "", "region", "zone" or "subzone"
+
+	tagLabel int // 0-based label index that tag_label designates as the tag; -1 disables tag parsing.
+
 	Next plugin.Handler
 }
 
@@ -48,67 +55,108 @@ func (t *Traffic) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	m.SetReply(r)
 	m.Authoritative = true
 
-	sockaddr, ok := t.c.Select(cluster, t.health)
+	if t.signer != nil && state.QType() == dns.TypeDNSKEY && state.Zone != "" && state.Name() == state.Zone {
+		m.Answer = []dns.RR{t.signer.key}
+		return t.writeMsg(state, m, cluster)
+	}
+
+	tag := ""
+
+	sockaddr, ok := t.selectEndpoint(state, cluster)
 	if !ok {
 		// ok this cluster doesn't exist, potentially due to extra labels, which may be garbage or legit queries:
 		// legit is:
 		// endpoint-N.cluster
 		// _grpclb._tcp.cluster
 		// _tcp.cluster
+		// <tag>.cluster (when tag_label is configured), catalog-style
 		labels := dns.SplitDomainName(cluster)
-		switch len(labels) {
-		case 2:
-			// endpoint or _tcp
-			if strings.ToLower(labels[0]) == "_tcp" {
-				// nodata, because empty non-terminal
+		if len(labels) < 2 {
+			m.Ns = soa(state.Zone)
+			m.Rcode = dns.RcodeNameError
+			return t.writeMsg(state, m, cluster)
+		}
+
+		q := t.parseLabels(labels)
+
+		switch {
+		case !q.ok:
+			// A label didn't fit endpoint-N/_tcp/_grpclb/tag shape: garbage
+			// under the zone, not an empty non-terminal.
+			m.Ns = soa(state.Zone)
+			m.Rcode = dns.RcodeNameError
+			return t.writeMsg(state, m, cluster)
+
+		case q.proto == "_grpclb._tcp":
+			// OK, _grcplb._tcp query; we need to return the endpoint for the mgmt cluster *NOT* the cluster
+			// we got the query for. This should exist, but we'll check later anyway.
+			cluster = t.mgmt
+			sockaddr, _ = t.selectEndpoint(state, cluster)
+
+		case q.proto == "_tcp" && q.endpoint == "" && q.tag == "":
+			// nodata, because empty non-terminal
+			m.Ns = soa(state.Zone)
+			m.Rcode = dns.RcodeSuccess
+			return t.writeMsg(state, m, cluster)
+
+		case q.endpoint != "":
+			// recheck if the cluster exists.
+			cluster = q.cluster
+			sockaddr, ok = t.selectEndpoint(state, cluster)
+			if !ok {
 				m.Ns = soa(state.Zone)
-				m.Rcode = dns.RcodeSuccess
-				w.WriteMsg(m)
-				return 0, nil
-			}
-			if strings.HasPrefix(strings.ToLower(labels[0]), "endpoint-") {
-				// recheck if the cluster exist.
-				cluster = labels[1]
-				sockaddr, ok = t.c.Select(cluster, t.health)
-				if !ok {
-					m.Ns = soa(state.Zone)
-					m.Rcode = dns.RcodeNameError
-					w.WriteMsg(m)
-					return 0, nil
-				}
-				return t.serveEndpoint(ctx, state, labels[0], cluster)
+				m.Rcode = dns.RcodeNameError
+				return t.writeMsg(state, m, cluster)
 			}
-		case 3:
-			if strings.ToLower(labels[0]) != "_grpclb" || strings.ToLower(labels[1]) != "_tcp" {
+			return t.serveEndpoint(ctx, state, q.endpoint, cluster)
+
+		case q.tag != "":
+			cluster = q.cluster
+			tag = q.tag
+			sockaddr, ok = t.selectTag(state, cluster, q.tag)
+			if !ok {
 				m.Ns = soa(state.Zone)
 				m.Rcode = dns.RcodeNameError
-				w.WriteMsg(m)
-				return 0, nil
+				return t.writeMsg(state, m, cluster)
 			}
-			// OK, _grcplb._tcp query; we need to return the endpoint for the mgmt cluster *NOT* the cluster
-			// we got the query for. This should exist, but we'll check later anyway.
-			cluster = t.mgmt
-			sockaddr, _ = t.c.Select(cluster, t.health)
-			break
+			if sockaddr == nil {
+				// cluster exists, but no endpoint carries this tag: empty non-terminal.
+				m.Ns = soa(state.Zone)
+				m.Rcode = dns.RcodeSuccess
+				return t.writeMsg(state, m, cluster)
+			}
+
+		case t.tagLabel >= 0:
+			// Every label was recognized, but their combination (e.g. a lone
+			// _grpclb) names no record. With the catalog/tag feature
+			// configured this is treated as an empty non-terminal rather
+			// than NXDOMAIN; unrecognized labels were already NXDOMAINed above.
+			m.Ns = soa(state.Zone)
+			m.Rcode = dns.RcodeSuccess
+			return t.writeMsg(state, m, cluster)
+
 		default:
+			// tag_label isn't configured, so there's no legitimate query shape
+			// left to account for: this is garbage under the zone.
 			m.Ns = soa(state.Zone)
 			m.Rcode = dns.RcodeNameError
-			w.WriteMsg(m)
-			return 0, nil
+			return t.writeMsg(state, m, cluster)
 		}
 	}
 
 	if sockaddr == nil {
 		log.Debugf("No (healthy) endpoints found for %q", cluster)
 		m.Ns = soa(state.Zone)
-		w.WriteMsg(m)
-		return 0, nil
+		return t.writeMsg(state, m, cluster)
 	}
 
+	present := []uint16(nil)
+
 	switch state.QType() {
 	case dns.TypeA:
 		if sockaddr.Address().To4() == nil { // it's an IPv6 address, return nodata in that case.
 			m.Ns = soa(state.Zone)
+			present = []uint16{dns.TypeAAAA}
 			break
 		}
 		m.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 5}, A: sockaddr.Address()}}
@@ -116,11 +164,17 @@ func (t *Traffic) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	case dns.TypeAAAA:
 		if sockaddr.Address().To4() != nil { // it's an IPv4 address, return nodata in that case.
 			m.Ns = soa(state.Zone)
+			present = []uint16{dns.TypeA}
 			break
 		}
 		m.Answer = []dns.RR{&dns.AAAA{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 5}, AAAA: sockaddr.Address()}}
 	case dns.TypeSRV:
-		sockaddrs, _ := t.c.All(cluster, t.health)
+		var sockaddrs []*xds.SocketAddress
+		if tag != "" {
+			sockaddrs, _ = t.allTag(state, cluster, tag)
+		} else {
+			sockaddrs, _ = t.allEndpoints(state, cluster)
+		}
 		m.Answer = make([]dns.RR, 0, len(sockaddrs))
 		m.Extra = make([]dns.RR, 0, len(sockaddrs))
 		for i, sa := range sockaddrs {
@@ -128,7 +182,7 @@ func (t *Traffic) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 
 			m.Answer = append(m.Answer, &dns.SRV{
 				Hdr:      dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: 5},
-				Priority: 100, Weight: 100, Port: sa.Port(), Target: target})
+				Priority: clampUint16(sa.Priority), Weight: clampUint16(sa.Weight), Port: sa.Port(), Target: target})
 
 			if sa.Address().To4() == nil {
 				m.Extra = append(m.Extra, &dns.AAAA{Hdr: dns.RR_Header{Name: target, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 5}, AAAA: sa.Address()})
@@ -140,8 +194,14 @@ func (t *Traffic) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		m.Ns = soa(state.Zone)
 	}
 
-	w.WriteMsg(m)
-	return 0, nil
+	scope := uint8(0)
+	ip, subnet := clientSubnet(state)
+	if t.preferLocality != "" {
+		scope = localityScope(t.localities, ip)
+	}
+	echoECS(state, subnet, scope, m)
+
+	return t.writeMsg(state, m, cluster, present...)
 }
 
 func (t *Traffic) serveEndpoint(ctx context.Context, state request.Request, endpoint, cluster string) (int, error) {
@@ -154,8 +214,7 @@ func (t *Traffic) serveEndpoint(ctx context.Context, state request.Request, endp
 	if i == -1 || i == len(endpoint) {
 		m.Ns = soa(state.Zone)
 		m.Rcode = dns.RcodeNameError
-		state.W.WriteMsg(m)
-		return 0, nil
+		return t.writeMsg(state, m, cluster)
 	}
 
 	end := endpoint[i+1:] // +1 to remove '-'
@@ -163,23 +222,23 @@ func (t *Traffic) serveEndpoint(ctx context.Context, state request.Request, endp
 	if err != nil {
 		m.Ns = soa(state.Zone)
 		m.Rcode = dns.RcodeNameError
-		state.W.WriteMsg(m)
-		return 0, nil
+		return t.writeMsg(state, m, cluster)
 	}
 
-	sockaddrs, _ := t.c.All(cluster, t.health)
+	sockaddrs, _ := t.allEndpoints(state, cluster)
 	if len(sockaddrs) < nr {
 		m.Ns = soa(state.Zone)
 		m.Rcode = dns.RcodeNameError
-		state.W.WriteMsg(m)
-		return 0, nil
+		return t.writeMsg(state, m, cluster)
 	}
 
 	addr := sockaddrs[nr].Address()
+	present := []uint16(nil)
 	switch state.QType() {
 	case dns.TypeA:
 		if addr.To4() == nil { // it's an IPv6 address, return nodata in that case.
 			m.Ns = soa(state.Zone)
+			present = []uint16{dns.TypeAAAA}
 			break
 		}
 		m.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 5}, A: addr}}
@@ -187,6 +246,7 @@ func (t *Traffic) serveEndpoint(ctx context.Context, state request.Request, endp
 	case dns.TypeAAAA:
 		if addr.To4() != nil { // it's an IPv4 address, return nodata in that case.
 			m.Ns = soa(state.Zone)
+			present = []uint16{dns.TypeA}
 			break
 		}
 		m.Answer = []dns.RR{&dns.AAAA{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 5}, AAAA: addr}}
@@ -194,13 +254,34 @@ func (t *Traffic) serveEndpoint(ctx context.Context, state request.Request, endp
 		m.Ns = soa(state.Zone)
 	}
 
-	state.W.WriteMsg(m)
-	return 0, nil
+	return t.writeMsg(state, m, cluster, present...)
 }
 
 // Name implements the plugin.Handler interface.
 func (t *Traffic) Name() string { return "traffic" }
 
+// writeMsg online-signs m when the plugin is configured with a DNSSEC signer
+// and the query carried the DO bit, records the request_count_total metric,
+// and then writes m to the client. present, if given, lists RRtypes that
+// genuinely exist at the queried name so a synthesized NSEC doesn't deny them.
+func (t *Traffic) writeMsg(state request.Request, m *dns.Msg, cluster string, present ...uint16) (int, error) {
+	if t.signer != nil && state.Do() {
+		t.signer.sign(m, state.Zone, present...)
+	}
+	requestCount.WithLabelValues(cluster, dns.TypeToString[state.QType()], dns.RcodeToString[m.Rcode]).Inc()
+	state.W.WriteMsg(m)
+	return 0, nil
+}
+
+// clampUint16 clamps an xDS uint32 (priority or weight) to the uint16 range
+// dns.SRV expects.
+func clampUint16(v uint32) uint16 {
+	if v > 65535 {
+		return 65535
+	}
+	return uint16(v)
+}
+
 // soa returns a synthetic so for this zone.
 func soa(z string) []dns.RR {
 	return []dns.RR{&dns.SOA{
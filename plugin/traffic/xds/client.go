@@ -0,0 +1,251 @@
+// Package xds implements a client that tracks cluster membership through the
+// envoy xDS protocol (CDS/EDS) and exposes the bits of it the traffic plugin
+// needs to answer DNS queries.
+package xds
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// LBPolicy is the algorithm Client.Select uses to pick a single endpoint out
+// of the lowest priority tier that still has healthy endpoints.
+type LBPolicy int
+
+const (
+	// RoundRobin cycles through the tier in the order endpoints were received.
+	RoundRobin LBPolicy = iota
+	// Random picks a uniformly random endpoint from the tier.
+	Random
+	// WeightedRandom picks a random endpoint from the tier, weighted by each
+	// endpoint's EDS load_balancing_weight.
+	WeightedRandom
+	// Priority is an alias for the (always on) priority-tier fallback with
+	// round robin used to pick within the winning tier.
+	Priority
+)
+
+// ParseLBPolicy turns the Corefile token for lb_policy into an LBPolicy.
+func ParseLBPolicy(s string) (LBPolicy, bool) {
+	switch s {
+	case "round_robin":
+		return RoundRobin, true
+	case "random":
+		return Random, true
+	case "weighted_random":
+		return WeightedRandom, true
+	case "priority":
+		return Priority, true
+	}
+	return RoundRobin, false
+}
+
+// Client tracks the clusters (and their endpoints) learned through xDS.
+type Client struct {
+	mu       sync.RWMutex
+	clusters map[string]*cluster
+
+	// LBPolicy controls how Select picks a single endpoint within a tier.
+	LBPolicy LBPolicy
+
+	rand *rand.Rand
+}
+
+// cluster holds the endpoints of a single xDS cluster, bucketed by priority.
+type cluster struct {
+	// tiers maps priority -> endpoints received for that priority. Priority 0
+	// is preferred over 1, 2, etc., matching the xDS convention.
+	tiers map[uint32][]*SocketAddress
+	// round is a cursor used by round-robin selection.
+	round uint64
+}
+
+// New returns a new, empty Client.
+func New() *Client {
+	return &Client{
+		clusters: make(map[string]*cluster),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Select returns a single endpoint for cluster name. If health is true, only
+// healthy endpoints are considered, preferring the lowest priority tier that
+// still has at least one (healthy) endpoint. The second return value reports
+// whether the cluster is known at all; a nil SocketAddress with ok == true
+// means the cluster exists but has no (healthy) endpoints.
+func (c *Client) Select(name string, health bool) (*SocketAddress, bool) {
+	return c.SelectNear(name, health, Locality{}, "")
+}
+
+// SelectNear behaves like Select, but first narrows the winning priority tier
+// down to the endpoints whose Locality matches want at the given precision
+// ("region", "zone" or "subzone"); it falls back to the full tier when
+// nothing matches, or when level is "" (no locality preference configured).
+func (c *Client) SelectNear(name string, health bool, want Locality, level string) (*SocketAddress, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cl, ok := c.clusters[name]
+	if !ok {
+		return nil, false
+	}
+
+	tier := cl.lowestHealthyTier(health)
+	if len(tier) == 0 {
+		return nil, true
+	}
+	if near := filterLocality(tier, want, level); len(near) > 0 {
+		tier = near
+	}
+
+	switch c.LBPolicy {
+	case WeightedRandom:
+		return weightedRandom(tier, c.rand), true
+	case Random:
+		return tier[c.rand.Intn(len(tier))], true
+	default: // RoundRobin, Priority
+		cl.round++
+		return tier[cl.round%uint64(len(tier))], true
+	}
+}
+
+// All returns every (healthy) endpoint for cluster name, across all priority
+// tiers, used to build SRV responses.
+func (c *Client) All(name string, health bool) ([]*SocketAddress, bool) {
+	return c.AllNear(name, health, Locality{}, "")
+}
+
+// AllNear behaves like All, but orders endpoints whose Locality matches want
+// at the given precision ahead of the rest.
+func (c *Client) AllNear(name string, health bool, want Locality, level string) ([]*SocketAddress, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cl, ok := c.clusters[name]
+	if !ok {
+		return nil, false
+	}
+
+	all := make([]*SocketAddress, 0)
+	for _, p := range cl.sortedPriorities() {
+		for _, sa := range cl.tiers[p] {
+			if health && !sa.healthy {
+				continue
+			}
+			all = append(all, sa)
+		}
+	}
+
+	near := filterLocality(all, want, level)
+	if len(near) == 0 || len(near) == len(all) {
+		return all, true
+	}
+	rest := make([]*SocketAddress, 0, len(all)-len(near))
+	nearSet := make(map[*SocketAddress]bool, len(near))
+	for _, sa := range near {
+		nearSet[sa] = true
+	}
+	for _, sa := range all {
+		if !nearSet[sa] {
+			rest = append(rest, sa)
+		}
+	}
+	return append(near, rest...), true
+}
+
+// lowestHealthyTier returns the endpoints of the lowest priority tier that
+// has at least one endpoint passing the health filter, falling back to the
+// next tier when the current one is exhausted.
+func (cl *cluster) lowestHealthyTier(health bool) []*SocketAddress {
+	for _, p := range cl.sortedPriorities() {
+		tier := cl.tiers[p]
+		if !health {
+			if len(tier) > 0 {
+				return tier
+			}
+			continue
+		}
+		healthy := make([]*SocketAddress, 0, len(tier))
+		for _, sa := range tier {
+			if sa.healthy {
+				healthy = append(healthy, sa)
+			}
+		}
+		if len(healthy) > 0 {
+			return healthy
+		}
+	}
+	return nil
+}
+
+// sortedPriorities returns the priorities present in cl, ascending (0 first).
+func (cl *cluster) sortedPriorities() []uint32 {
+	priorities := make([]uint32, 0, len(cl.tiers))
+	for p := range cl.tiers {
+		priorities = append(priorities, p)
+	}
+	// insertion sort; the number of priority tiers is tiny.
+	for i := 1; i < len(priorities); i++ {
+		for j := i; j > 0 && priorities[j-1] > priorities[j]; j-- {
+			priorities[j-1], priorities[j] = priorities[j], priorities[j-1]
+		}
+	}
+	return priorities
+}
+
+// weightedRandom picks an endpoint from tier using a cumulative-weight
+// prefix-sum, treating a zero (unset) weight as 1.
+func weightedRandom(tier []*SocketAddress, r *rand.Rand) *SocketAddress {
+	total := uint64(0)
+	for _, sa := range tier {
+		w := sa.Weight
+		if w == 0 {
+			w = 1
+		}
+		total += uint64(w)
+	}
+	if total == 0 {
+		return tier[r.Intn(len(tier))]
+	}
+
+	target := uint64(r.Int63n(int64(total)))
+	sum := uint64(0)
+	for _, sa := range tier {
+		w := sa.Weight
+		if w == 0 {
+			w = 1
+		}
+		sum += uint64(w)
+		if target < sum {
+			return sa
+		}
+	}
+	return tier[len(tier)-1]
+}
+
+// SetEndpoints replaces the endpoints known for cluster name. It is called
+// from the EDS (ClusterLoadAssignment) handler of the ADS receive loop with
+// the endpoints bucketed by their LocalityLbEndpoints.priority.
+func (c *Client) SetEndpoints(name string, tiers map[uint32][]*SocketAddress) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cl, ok := c.clusters[name]
+	if !ok {
+		cl = &cluster{}
+		c.clusters[name] = cl
+	}
+	cl.tiers = tiers
+
+	healthy := 0
+	for _, tier := range tiers {
+		for _, sa := range tier {
+			if sa.healthy {
+				healthy++
+			}
+		}
+	}
+	HealthyEndpoints.WithLabelValues(name).Set(float64(healthy))
+	UpdatesTotal.WithLabelValues("eds").Inc()
+}
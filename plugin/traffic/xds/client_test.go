@@ -0,0 +1,66 @@
+package xds
+
+import (
+	"net"
+	"testing"
+)
+
+func addr(ip string, weight, priority uint32, healthy bool) *SocketAddress {
+	return &SocketAddress{address: net.ParseIP(ip), port: 53, Weight: weight, Priority: priority, healthy: healthy}
+}
+
+func TestSelectPriorityFallback(t *testing.T) {
+	c := New()
+	c.SetEndpoints("web", map[uint32][]*SocketAddress{
+		0: {addr("10.0.0.1", 1, 0, false)}, // unhealthy, should be skipped
+		1: {addr("10.0.0.2", 1, 1, true)},
+	})
+
+	sa, ok := c.Select("web", true)
+	if !ok {
+		t.Fatal("expected cluster to be known")
+	}
+	if sa == nil || sa.Address().String() != "10.0.0.2" {
+		t.Fatalf("expected fallback to priority 1 endpoint, got %v", sa)
+	}
+}
+
+func TestSelectUnknownCluster(t *testing.T) {
+	c := New()
+	if _, ok := c.Select("nope", true); ok {
+		t.Fatal("expected unknown cluster to report ok == false")
+	}
+}
+
+func TestSelectNoHealthyEndpoints(t *testing.T) {
+	c := New()
+	c.SetEndpoints("web", map[uint32][]*SocketAddress{0: {addr("10.0.0.1", 1, 0, false)}})
+
+	sa, ok := c.Select("web", true)
+	if !ok {
+		t.Fatal("expected cluster to be known")
+	}
+	if sa != nil {
+		t.Fatalf("expected no healthy endpoint, got %v", sa)
+	}
+}
+
+func TestWeightedRandomDistribution(t *testing.T) {
+	c := New()
+	c.LBPolicy = WeightedRandom
+	c.SetEndpoints("web", map[uint32][]*SocketAddress{
+		0: {addr("10.0.0.1", 90, 0, true), addr("10.0.0.2", 10, 0, true)},
+	})
+
+	counts := map[string]int{}
+	const n = 10000
+	for i := 0; i < n; i++ {
+		sa, _ := c.Select("web", true)
+		counts[sa.Address().String()]++
+	}
+
+	heavy := counts["10.0.0.1"]
+	if heavy < n*75/100 || heavy > n*99/100 {
+		t.Fatalf("expected ~90%% of selections to favor the heavier endpoint, got %d/%d", heavy, n)
+	}
+}
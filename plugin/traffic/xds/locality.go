@@ -0,0 +1,46 @@
+package xds
+
+// Locality mirrors the envoy xDS Locality message (region/zone/sub_zone) that
+// LocalityLbEndpoints carries alongside each tier of endpoints.
+type Locality struct {
+	Region  string
+	Zone    string
+	SubZone string
+}
+
+// matches reports whether l and want agree down to the given precision:
+// "region", "zone" or "subzone". An empty field in want is treated as a
+// wildcard for that level.
+func (l Locality) matches(want Locality, level string) bool {
+	if want.Region != "" && l.Region != want.Region {
+		return false
+	}
+	if level == "region" {
+		return true
+	}
+	if want.Zone != "" && l.Zone != want.Zone {
+		return false
+	}
+	if level == "zone" {
+		return true
+	}
+	if want.SubZone != "" && l.SubZone != want.SubZone {
+		return false
+	}
+	return true
+}
+
+// filterLocality returns the subset of endpoints whose Locality matches want
+// at the given precision. The empty slice is returned when nothing matches.
+func filterLocality(endpoints []*SocketAddress, want Locality, level string) []*SocketAddress {
+	if level == "" {
+		return nil
+	}
+	near := make([]*SocketAddress, 0, len(endpoints))
+	for _, sa := range endpoints {
+		if sa.Locality.matches(want, level) {
+			near = append(near, sa)
+		}
+	}
+	return near
+}
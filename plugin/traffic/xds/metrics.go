@@ -0,0 +1,28 @@
+package xds
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// HealthyEndpoints is the number of healthy endpoints currently known for
+	// a cluster; it is updated whenever the Client processes a new
+	// ClusterLoadAssignment, independent of query traffic.
+	HealthyEndpoints = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "traffic",
+		Name:      "healthy_endpoints",
+		Help:      "Number of healthy endpoints known for a cluster.",
+	}, []string{"cluster"})
+
+	// UpdatesTotal counts xDS push notifications the Client has received, by
+	// type (e.g. "eds").
+	UpdatesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "traffic",
+		Name:      "xds_updates_total",
+		Help:      "Counter of xDS updates received, by type.",
+	}, []string{"type"})
+)
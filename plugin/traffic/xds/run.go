@@ -0,0 +1,140 @@
+package xds
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+
+	"github.com/gogo/protobuf/types"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+)
+
+var log = clog.NewWithPlugin("traffic")
+
+// edsTypeURL is the DiscoveryRequest TypeUrl for EDS (ClusterLoadAssignment)
+// resources, the only resource type this plugin needs from the management
+// server.
+const edsTypeURL = "type.googleapis.com/envoy.api.v2.ClusterLoadAssignment"
+
+// tagFilterKey and tagsMetadataField locate the tags this plugin filters on
+// inside an LbEndpoint's xDS Metadata: FilterMetadata["envoy.lb"]["tags"], a
+// ListValue of strings, following the same "envoy.lb" filter namespace Envoy
+// itself uses for subset load balancing metadata.
+const (
+	tagFilterKey      = "envoy.lb"
+	tagsMetadataField = "tags"
+)
+
+// Run dials the xDS management server at addr as node and streams EDS
+// (ClusterLoadAssignment) updates into c for as long as ctx is live, calling
+// SetEndpoints for every cluster the server pushes. It blocks until ctx is
+// canceled or the stream fails, and is meant to run in its own goroutine,
+// started from the traffic plugin's OnStartup and reconnecting (via the
+// caller) on error.
+func (c *Client) Run(ctx context.Context, addr, node string, tlsConfig *tls.Config) error {
+	creds := grpc.WithInsecure()
+	if tlsConfig != nil {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+
+	conn, err := grpc.DialContext(ctx, addr, creds, grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := xdsapi.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	req := &xdsapi.DiscoveryRequest{Node: &core.Node{Id: node}, TypeUrl: edsTypeURL}
+	if err := stream.Send(req); err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, res := range resp.Resources {
+			var cla xdsapi.ClusterLoadAssignment
+			if err := proto.Unmarshal(res.Value, &cla); err != nil {
+				log.Warningf("Failed to unmarshal ClusterLoadAssignment: %s", err)
+				continue
+			}
+			c.SetEndpoints(cla.ClusterName, tiersFromAssignment(&cla))
+		}
+
+		req.VersionInfo = resp.VersionInfo
+		req.ResponseNonce = resp.Nonce
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+	}
+}
+
+// tiersFromAssignment converts an EDS ClusterLoadAssignment into the
+// priority-bucketed endpoint map SetEndpoints expects, carrying over each
+// endpoint's load_balancing_weight, LocalityLbEndpoints.priority and locality.
+func tiersFromAssignment(cla *xdsapi.ClusterLoadAssignment) map[uint32][]*SocketAddress {
+	tiers := make(map[uint32][]*SocketAddress)
+	for _, lle := range cla.Endpoints {
+		loc := Locality{Region: lle.GetLocality().GetRegion(), Zone: lle.GetLocality().GetZone(), SubZone: lle.GetLocality().GetSubZone()}
+
+		for _, lbe := range lle.LbEndpoints {
+			sockaddr := lbe.GetEndpoint().GetAddress().GetSocketAddress()
+			if sockaddr == nil {
+				continue
+			}
+
+			healthStatus := lbe.GetHealthStatus()
+			sa := &SocketAddress{
+				address:  net.ParseIP(sockaddr.GetAddress()),
+				port:     sockaddr.GetPortValue(),
+				Weight:   lbe.GetLoadBalancingWeight().GetValue(),
+				Priority: lle.Priority,
+				Locality: loc,
+				Tags:     tagsFromMetadata(lbe.GetMetadata()),
+				healthy:  healthStatus == core.HealthStatus_HEALTHY || healthStatus == core.HealthStatus_UNKNOWN,
+			}
+			tiers[lle.Priority] = append(tiers[lle.Priority], sa)
+		}
+	}
+	return tiers
+}
+
+// tagsFromMetadata extracts the SelectFiltered/AllFiltered tags for an
+// endpoint out of its xDS Metadata, reading a "tags" string-list field out of
+// the "envoy.lb" filter metadata namespace. A missing namespace, field, or
+// malformed value just yields no tags, rather than an error: tagging is an
+// optional, best-effort annotation on top of the endpoint.
+func tagsFromMetadata(md *core.Metadata) []string {
+	fields := md.GetFilterMetadata()[tagFilterKey].GetFields()
+	list := fields[tagsMetadataField].GetListValue()
+	if list == nil {
+		return nil
+	}
+
+	tags := make([]string, 0, len(list.Values))
+	for _, v := range list.Values {
+		if s, ok := v.GetKind().(*types.Value_StringValue); ok {
+			tags = append(tags, s.StringValue)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,99 @@
+package xds
+
+import (
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
+
+	"github.com/gogo/protobuf/types"
+)
+
+// tagMetadata builds the Metadata an LbEndpoint would carry for tags, in the
+// "envoy.lb" / "tags" shape tagsFromMetadata reads.
+func tagMetadata(tags ...string) *core.Metadata {
+	values := make([]*types.Value, len(tags))
+	for i, t := range tags {
+		values[i] = &types.Value{Kind: &types.Value_StringValue{StringValue: t}}
+	}
+	return &core.Metadata{
+		FilterMetadata: map[string]*types.Struct{
+			tagFilterKey: {
+				Fields: map[string]*types.Value{
+					tagsMetadataField: {Kind: &types.Value_ListValue{ListValue: &types.ListValue{Values: values}}},
+				},
+			},
+		},
+	}
+}
+
+func lbEndpoint(ip string, port, weight uint32, metadata *core.Metadata) *endpoint.LbEndpoint {
+	return &endpoint.LbEndpoint{
+		HealthStatus:        core.HealthStatus_HEALTHY,
+		LoadBalancingWeight: &types.UInt32Value{Value: weight},
+		Metadata:            metadata,
+		HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+			Endpoint: &endpoint.Endpoint{
+				Address: &core.Address{
+					Address: &core.Address_SocketAddress{
+						SocketAddress: &core.SocketAddress{
+							Address:       ip,
+							PortSpecifier: &core.SocketAddress_PortValue{PortValue: port},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestTiersFromAssignment(t *testing.T) {
+	cla := &xdsapi.ClusterLoadAssignment{
+		ClusterName: "web",
+		Endpoints: []*endpoint.LocalityLbEndpoints{
+			{
+				Priority:    0,
+				Locality:    &core.Locality{Region: "us-east"},
+				LbEndpoints: []*endpoint.LbEndpoint{lbEndpoint("10.0.0.1", 53, 2, tagMetadata("canary"))},
+			},
+		},
+	}
+
+	tiers := tiersFromAssignment(cla)
+	tier, ok := tiers[0]
+	if !ok || len(tier) != 1 {
+		t.Fatalf("expected one endpoint in priority 0, got %v", tiers)
+	}
+
+	sa := tier[0]
+	if sa.Address().String() != "10.0.0.1" || sa.Port() != 53 || sa.Weight != 2 {
+		t.Fatalf("unexpected endpoint: %+v", sa)
+	}
+	if sa.Locality.Region != "us-east" {
+		t.Fatalf("expected locality to carry over, got %+v", sa.Locality)
+	}
+	if !sa.Healthy() {
+		t.Fatal("expected endpoint to be healthy")
+	}
+	if len(sa.Tags) != 1 || sa.Tags[0] != "canary" {
+		t.Fatalf("expected Tags to carry the \"canary\" xDS metadata tag, got %v", sa.Tags)
+	}
+}
+
+func TestTiersFromAssignmentSetEndpoints(t *testing.T) {
+	cla := &xdsapi.ClusterLoadAssignment{
+		ClusterName: "web",
+		Endpoints: []*endpoint.LocalityLbEndpoints{
+			{LbEndpoints: []*endpoint.LbEndpoint{lbEndpoint("10.0.0.1", 53, 1, tagMetadata("canary"))}},
+		},
+	}
+
+	c := New()
+	c.SetEndpoints(cla.ClusterName, tiersFromAssignment(cla))
+
+	sa, ok := c.SelectFiltered("web", "canary", true)
+	if !ok || sa == nil || sa.Address().String() != "10.0.0.1" {
+		t.Fatalf("expected the canary-tagged endpoint learned via tiersFromAssignment, got %v (ok=%v)", sa, ok)
+	}
+}
@@ -0,0 +1,36 @@
+package xds
+
+import "net"
+
+// SocketAddress is a single endpoint inside a cluster, as tracked from the xDS
+// EDS (ClusterLoadAssignment) response.
+type SocketAddress struct {
+	address net.IP
+	port    uint32
+
+	// Weight is the EDS LbEndpoint.load_balancing_weight for this endpoint. It
+	// is 0 when the control plane does not set it; weighted random selection
+	// treats 0 as 1.
+	Weight uint32
+	// Priority is the LocalityLbEndpoints.priority this endpoint was received
+	// in; 0 is the highest (most preferred) priority.
+	Priority uint32
+
+	// Locality is the LocalityLbEndpoints.locality this endpoint was received in.
+	Locality Locality
+
+	// Tags are the filter-chain tags from the endpoint's xDS Metadata, used by
+	// SelectFiltered to answer tag/service-catalog style queries.
+	Tags []string
+
+	healthy bool
+}
+
+// Address returns the IP address of the endpoint.
+func (s *SocketAddress) Address() net.IP { return s.address }
+
+// Port returns the port of the endpoint.
+func (s *SocketAddress) Port() uint16 { return uint16(s.port) }
+
+// Healthy returns whether the endpoint is currently healthy.
+func (s *SocketAddress) Healthy() bool { return s.healthy }
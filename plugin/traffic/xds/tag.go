@@ -0,0 +1,84 @@
+package xds
+
+// SelectFiltered returns a single endpoint for cluster name whose xDS
+// endpoint metadata carries tag, applying the same tier/LBPolicy rules as
+// Select. ok reports whether the cluster itself is known; a nil
+// SocketAddress with ok == true means the cluster exists but no (healthy)
+// endpoint carries tag.
+func (c *Client) SelectFiltered(name, tag string, health bool) (*SocketAddress, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cl, ok := c.clusters[name]
+	if !ok {
+		return nil, false
+	}
+
+	var tagged []*SocketAddress
+	for _, p := range cl.sortedPriorities() {
+		for _, sa := range filterTag(cl.tiers[p], tag) {
+			if !health || sa.healthy {
+				tagged = append(tagged, sa)
+			}
+		}
+		if len(tagged) > 0 {
+			break
+		}
+	}
+	if len(tagged) == 0 {
+		return nil, true
+	}
+
+	switch c.LBPolicy {
+	case WeightedRandom:
+		return weightedRandom(tagged, c.rand), true
+	case Random:
+		return tagged[c.rand.Intn(len(tagged))], true
+	default: // RoundRobin, Priority
+		cl.round++
+		return tagged[cl.round%uint64(len(tagged))], true
+	}
+}
+
+// AllFiltered returns every (healthy) endpoint for cluster name whose xDS
+// endpoint metadata carries tag, across all priority tiers, used to build
+// tag-filtered SRV responses. ok reports whether the cluster itself is known.
+func (c *Client) AllFiltered(name, tag string, health bool) ([]*SocketAddress, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cl, ok := c.clusters[name]
+	if !ok {
+		return nil, false
+	}
+
+	all := make([]*SocketAddress, 0)
+	for _, p := range cl.sortedPriorities() {
+		for _, sa := range cl.tiers[p] {
+			if health && !sa.healthy {
+				continue
+			}
+			all = append(all, sa)
+		}
+	}
+
+	return filterTag(all, tag), true
+}
+
+// filterTag returns the subset of endpoints whose Tags include tag. An empty
+// tag matches everything (no filtering).
+func filterTag(endpoints []*SocketAddress, tag string) []*SocketAddress {
+	if tag == "" {
+		return endpoints
+	}
+	tagged := make([]*SocketAddress, 0, len(endpoints))
+	for _, sa := range endpoints {
+		for _, got := range sa.Tags {
+			if got == tag {
+				tagged = append(tagged, sa)
+				break
+			}
+		}
+	}
+	return tagged
+}
@@ -0,0 +1,26 @@
+package xds
+
+import "testing"
+
+func TestSelectFiltered(t *testing.T) {
+	canary := addr("10.0.0.1", 1, 0, true)
+	canary.Tags = []string{"canary"}
+	stable := addr("10.0.0.2", 1, 0, true)
+	stable.Tags = []string{"stable"}
+
+	c := New()
+	c.SetEndpoints("web", map[uint32][]*SocketAddress{0: {canary, stable}})
+
+	sa, ok := c.SelectFiltered("web", "canary", true)
+	if !ok || sa == nil || sa.Address().String() != "10.0.0.1" {
+		t.Fatalf("expected the canary-tagged endpoint, got %v (ok=%v)", sa, ok)
+	}
+
+	sa, ok = c.SelectFiltered("web", "nope", true)
+	if !ok {
+		t.Fatal("expected cluster to be known")
+	}
+	if sa != nil {
+		t.Fatalf("expected no endpoint to carry an unknown tag, got %v", sa)
+	}
+}